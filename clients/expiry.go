@@ -101,7 +101,7 @@ func (s *expiry) timerCallback() {
 	// 1. check for will message available
 	if s.will != nil {
 		// publish if exists and wipe state
-		_ = s.messenger.Publish(s.will)
+		_ = publishWill(s.messenger, s.will)
 		s.will = nil
 		s.willIn = 0
 	}
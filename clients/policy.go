@@ -0,0 +1,39 @@
+package clients
+
+import (
+	"strings"
+
+	topicsTypes "github.com/VolantMQ/volantmq/topics/types"
+)
+
+// FilterBreadth describes how wide subscription topic filter is
+type FilterBreadth struct {
+	// TopLevel filter is "#" thus matches every topic
+	TopLevel bool
+	// MultiLevel filter ends with multi-level wildcard
+	MultiLevel bool
+	// SingleLevel number of single-level wildcards in the filter
+	SingleLevel int
+}
+
+// SubscribePolicy decides if subscription to the filter is allowed
+// denied subscriptions are refused with 0x80 return code
+type SubscribePolicy func(filter string, breadth FilterBreadth) bool
+
+// filterBreadth classify filter. Filter is expected to be without shared subscription prefix
+func filterBreadth(filter string) FilterBreadth {
+	var b FilterBreadth
+
+	for _, level := range strings.Split(filter, topicsTypes.SEP) {
+		switch level {
+		case topicsTypes.MWC:
+			b.MultiLevel = true
+		case topicsTypes.SWC:
+			b.SingleLevel++
+		}
+	}
+
+	b.TopLevel = filter == topicsTypes.MWC
+
+	return b
+}
@@ -37,10 +37,12 @@ type sessionConfig struct {
 	sessionEvents
 	subscriber            vlsubscriber.IFace
 	will                  *mqttp.Publish
+	subscribePolicy       SubscribePolicy
 	expireIn              *uint32
 	durable               bool
 	sharedSubscriptions   bool
 	subscriptionIDAllowed bool
+	wildcardSubscriptions bool
 	version               mqttp.ProtocolVersion
 }
 
@@ -130,7 +132,19 @@ func (s *session) SignalSubscribe(pkt *mqttp.Subscribe) (mqttp.IFace, error) {
 		}
 	}
 
+	duplicates := subscribeDuplicates(pkt)
+	idx := -1
+
 	err := pkt.ForEachTopic(func(t *mqttp.Topic) error {
+		idx++
+
+		// filter is listed again later in the packet, the last occurrence wins
+		// return code will be copied from it once all topics processed
+		if _, ok := duplicates[idx]; ok {
+			retCodes = append(retCodes, mqttp.CodeSuccess)
+			return nil
+		}
+
 		// V5.0
 		if t.ShareName() != "" {
 			// [MQTT-3.8.3-4] It is a Protocol Error to set the No Local bit to 1 on a Shared Subscription
@@ -144,6 +158,24 @@ func (s *session) SignalSubscribe(pkt *mqttp.Subscribe) (mqttp.IFace, error) {
 			}
 		}
 
+		breadth := filterBreadth(t.Filter())
+
+		// V5.0 [MQTT-3.2.2.3.14]
+		if !s.wildcardSubscriptions && (breadth.MultiLevel || breadth.SingleLevel > 0) {
+			if s.version == mqttp.ProtocolV50 {
+				retCodes = append(retCodes, mqttp.CodeWildcardSubscriptionsNotSupported)
+			} else {
+				retCodes = append(retCodes, mqttp.QosFailure)
+			}
+			return nil
+		}
+
+		// filter is too wide for the policy
+		if s.subscribePolicy != nil && !s.subscribePolicy(t.Filter(), breadth) {
+			retCodes = append(retCodes, mqttp.QosFailure)
+			return nil
+		}
+
 		var reason mqttp.ReasonCode
 
 		if e := s.permissions.ACL(s.id, s.username, t.Filter(), vlauth.AccessRead); errors.Is(e, vlauth.StatusAllow) {
@@ -175,6 +207,10 @@ func (s *session) SignalSubscribe(pkt *mqttp.Subscribe) (mqttp.IFace, error) {
 		return nil, err
 	}
 
+	for i, last := range duplicates {
+		retCodes[i] = retCodes[last]
+	}
+
 	if err = resp.AddReturnCodes(retCodes); err != nil {
 		return nil, err
 	}
@@ -311,7 +347,7 @@ func (s *session) SignalConnectionClose(params connection.DisconnectParams) {
 		}
 
 		if willIn == 0 {
-			if err := s.messenger.Publish(s.will); err != nil {
+			if err := publishWill(s.messenger, s.will); err != nil {
 				s.log.Error("Publish will message", zap.String("ClientID", s.id), zap.Error(err))
 			}
 			s.will = nil
@@ -358,3 +394,38 @@ func (s *session) SignalConnectionClose(params connection.DisconnectParams) {
 
 	s.stopReq.Do(func() {})
 }
+
+// subscribeDuplicates finds topic filters listed more than once within single SUBSCRIBE
+// returns index of each superseded filter mapped to index of its last occurrence
+func subscribeDuplicates(pkt *mqttp.Subscribe) map[int]int {
+	var filters []string
+	last := make(map[string]int)
+
+	_ = pkt.ForEachTopic(func(t *mqttp.Topic) error {
+		last[t.Full()] = len(filters)
+		filters = append(filters, t.Full())
+		return nil
+	})
+
+	duplicates := make(map[int]int)
+
+	for i, f := range filters {
+		if l := last[f]; l != i {
+			duplicates[i] = l
+		}
+	}
+
+	return duplicates
+}
+
+// publishWill publish will message and retain it if will retain flag set
+// [MQTT-3.1.2-17]
+func publishWill(messenger vltypes.TopicMessenger, will *mqttp.Publish) error {
+	if will.Retain() {
+		if err := messenger.Retain(will); err != nil {
+			return err
+		}
+	}
+
+	return messenger.Publish(will)
+}
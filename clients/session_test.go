@@ -0,0 +1,230 @@
+package clients // nolint: testpackage
+
+import (
+	"testing"
+
+	"github.com/VolantMQ/vlapi/mqttp"
+	"github.com/VolantMQ/vlapi/vlauth"
+	"github.com/VolantMQ/vlapi/vlsubscriber"
+	"github.com/VolantMQ/vlapi/vltypes"
+	"github.com/stretchr/testify/require"
+)
+
+type testPermissions struct{}
+
+func (p *testPermissions) ACL(_, _, _ string, _ vlauth.AccessType) error {
+	return vlauth.StatusAllow
+}
+
+type testSubscription struct {
+	filter string
+	qos    mqttp.QosType
+}
+
+type testSubscriber struct {
+	subscribed []testSubscription
+}
+
+var _ vlsubscriber.IFace = (*testSubscriber)(nil)
+
+func (s *testSubscriber) Subscriptions() vlsubscriber.Subscriptions { return nil }
+func (s *testSubscriber) UnSubscribe(string) error                  { return nil }
+func (s *testSubscriber) HasSubscriptions() bool                    { return false }
+func (s *testSubscriber) Online(vlsubscriber.Publisher)             {}
+func (s *testSubscriber) Offline(bool)                              {}
+func (s *testSubscriber) Hash() uintptr                             { return 0 }
+
+func (s *testSubscriber) Subscribe(filter string, p vlsubscriber.SubscriptionParams) (mqttp.QosType, []*mqttp.Publish, error) {
+	s.subscribed = append(s.subscribed, testSubscription{filter: filter, qos: p.Ops.QoS()})
+	return p.Ops.QoS(), nil, nil
+}
+
+func newTestSession(c sessionConfig) *session {
+	s := newSession(sessionPreConfig{
+		id:          "test",
+		permissions: &testPermissions{},
+	})
+
+	if c.subscriber == nil {
+		c.subscriber = &testSubscriber{}
+	}
+	s.sessionConfig = c
+
+	return s
+}
+
+func newTestSubscribe(t *testing.T, v mqttp.ProtocolVersion, filters ...string) *mqttp.Subscribe {
+	pkt := mqttp.NewSubscribe(v)
+	pkt.SetPacketID(1)
+
+	for _, f := range filters {
+		topic, err := mqttp.NewSubscribeTopic([]byte(f), mqttp.SubscriptionOptions(mqttp.QoS1))
+		require.NoError(t, err)
+		require.NoError(t, pkt.AddTopic(topic))
+	}
+
+	return pkt
+}
+
+func newTestSubscribeDuplicates(t *testing.T) *mqttp.Subscribe {
+	pkt := mqttp.NewSubscribe(mqttp.ProtocolV311)
+	pkt.SetPacketID(1)
+
+	for _, f := range []struct {
+		filter string
+		qos    mqttp.QosType
+	}{
+		{"a/b", mqttp.QoS0},
+		{"c/d", mqttp.QoS1},
+		{"a/b", mqttp.QoS2},
+	} {
+		topic, err := mqttp.NewSubscribeTopic([]byte(f.filter), mqttp.SubscriptionOptions(f.qos))
+		require.NoError(t, err)
+		require.NoError(t, pkt.AddTopic(topic))
+	}
+
+	return pkt
+}
+
+func TestSubscribeDuplicates(t *testing.T) {
+	require.Equal(t, map[int]int{0: 2}, subscribeDuplicates(newTestSubscribeDuplicates(t)))
+}
+
+func TestSignalSubscribeDuplicates(t *testing.T) {
+	sub := &testSubscriber{}
+	s := newTestSession(sessionConfig{
+		version:    mqttp.ProtocolV311,
+		subscriber: sub,
+	})
+
+	resp, err := s.SignalSubscribe(newTestSubscribeDuplicates(t))
+	require.NoError(t, err)
+	require.IsType(t, &mqttp.SubAck{}, resp)
+
+	// last occurrence of a/b wins
+	require.Equal(t, []testSubscription{
+		{filter: "c/d", qos: mqttp.QoS1},
+		{filter: "a/b", qos: mqttp.QoS2},
+	}, sub.subscribed)
+
+	require.Equal(t, []mqttp.ReasonCode{
+		mqttp.ReasonCode(mqttp.QoS2),
+		mqttp.ReasonCode(mqttp.QoS1),
+		mqttp.ReasonCode(mqttp.QoS2),
+	}, resp.(*mqttp.SubAck).ReturnCodes())
+}
+
+func TestFilterBreadth(t *testing.T) {
+	require.Equal(t, FilterBreadth{TopLevel: true, MultiLevel: true}, filterBreadth("#"))
+	require.Equal(t, FilterBreadth{MultiLevel: true}, filterBreadth("sport/#"))
+	require.Equal(t, FilterBreadth{MultiLevel: true, SingleLevel: 2}, filterBreadth("+/+/#"))
+	require.Equal(t, FilterBreadth{}, filterBreadth("sport/tennis"))
+}
+
+func TestSubscribePolicy(t *testing.T) {
+	s := newTestSession(sessionConfig{
+		version:               mqttp.ProtocolV311,
+		wildcardSubscriptions: true,
+		subscribePolicy: func(_ string, b FilterBreadth) bool {
+			return !b.TopLevel
+		},
+	})
+
+	resp, err := s.SignalSubscribe(newTestSubscribe(t, mqttp.ProtocolV311, "#", "sport/#"))
+	require.NoError(t, err)
+	require.IsType(t, &mqttp.SubAck{}, resp)
+	require.Equal(t, []mqttp.ReasonCode{mqttp.QosFailure, mqttp.ReasonCode(mqttp.QoS1)}, resp.(*mqttp.SubAck).ReturnCodes())
+}
+
+func TestSubscribeWildcardDisabled(t *testing.T) {
+	for _, v := range []mqttp.ProtocolVersion{mqttp.ProtocolV311, mqttp.ProtocolV50} {
+		s := newTestSession(sessionConfig{version: v})
+
+		resp, err := s.SignalSubscribe(newTestSubscribe(t, v, "sport/#", "sport/+/player", "sport/tennis"))
+		require.NoError(t, err)
+		require.IsType(t, &mqttp.SubAck{}, resp)
+
+		reason := mqttp.ReasonCode(mqttp.QosFailure)
+		if v == mqttp.ProtocolV50 {
+			reason = mqttp.CodeWildcardSubscriptionsNotSupported
+		}
+
+		require.Equal(t, []mqttp.ReasonCode{reason, reason, mqttp.ReasonCode(mqttp.QoS1)}, resp.(*mqttp.SubAck).ReturnCodes())
+	}
+}
+
+func TestSubscribeSharedDisabled(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		s := newTestSession(sessionConfig{
+			version:             mqttp.ProtocolV50,
+			sharedSubscriptions: enabled,
+		})
+
+		resp, err := s.SignalSubscribe(newTestSubscribe(t, mqttp.ProtocolV50, "$share/group/sport/tennis", "sport/tennis"))
+		require.NoError(t, err)
+		require.IsType(t, &mqttp.SubAck{}, resp)
+
+		reason := mqttp.CodeSharedSubscriptionNotSupported
+		if enabled {
+			reason = mqttp.ReasonCode(mqttp.QoS1)
+		}
+
+		require.Equal(t, []mqttp.ReasonCode{reason, mqttp.ReasonCode(mqttp.QoS1)}, resp.(*mqttp.SubAck).ReturnCodes())
+	}
+}
+
+func TestSubscribeIDDisabled(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		s := newTestSession(sessionConfig{
+			version:               mqttp.ProtocolV50,
+			subscriptionIDAllowed: enabled,
+		})
+
+		pkt := newTestSubscribe(t, mqttp.ProtocolV50, "sport/tennis")
+		require.NoError(t, pkt.PropertySet(mqttp.PropertySubscriptionIdentifier, uint32(5)))
+
+		resp, err := s.SignalSubscribe(pkt)
+		if !enabled {
+			require.Equal(t, mqttp.CodeSubscriptionIDNotSupported, err)
+			require.Nil(t, resp)
+			continue
+		}
+
+		require.NoError(t, err)
+		require.IsType(t, &mqttp.SubAck{}, resp)
+		require.Equal(t, []mqttp.ReasonCode{mqttp.ReasonCode(mqttp.QoS1)}, resp.(*mqttp.SubAck).ReturnCodes())
+	}
+}
+
+type testMessenger struct {
+	published []interface{}
+	retained  []vltypes.RetainObject
+}
+
+func (m *testMessenger) Publish(p interface{}) error {
+	m.published = append(m.published, p)
+	return nil
+}
+
+func (m *testMessenger) Retain(p vltypes.RetainObject) error {
+	m.retained = append(m.retained, p)
+	return nil
+}
+
+func TestPublishWill(t *testing.T) {
+	for _, retain := range []bool{false, true} {
+		will := mqttp.NewPublish(mqttp.ProtocolV311)
+		require.NoError(t, will.Set("will/topic", []byte("gone"), mqttp.QoS1, retain, false))
+
+		m := &testMessenger{}
+		require.NoError(t, publishWill(m, will))
+		require.Len(t, m.published, 1)
+
+		if retain {
+			require.Len(t, m.retained, 1)
+			require.Equal(t, "will/topic", m.retained[0].Topic())
+		} else {
+			require.Len(t, m.retained, 0)
+		}
+	}
+}
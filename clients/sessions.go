@@ -43,6 +43,8 @@ type Config struct {
 	Persist          vlpersistence.IFace
 	Metrics          metrics.Informer
 	OnReplaceAttempt func(string, bool)
+	SubscribePolicy  SubscribePolicy
+	GenClientID      connection.ClientIDGenerator
 	NodeName         string
 }
 
@@ -307,9 +309,11 @@ func (m *Manager) OnConnection(conn transport.Conn, authMngr *auth.Manager) (err
 		connection.MaxTxPacketSize(types.DefaultMaxPacketSize),
 		connection.MaxRxPacketSize(m.Options.MaxPacketSize),
 		connection.MaxRxTopicAlias(m.Options.MaxTopicAlias),
+		connection.MaxRxQoS(m.Options.MaxQoS),
 		connection.MaxTxTopicAlias(0),
 		connection.KeepAlive(m.Options.ConnectTimeout),
 		connection.Persistence(m.persistence),
+		connection.IDGenerator(m.GenClientID),
 	)
 	if err != nil {
 		return
@@ -361,9 +365,10 @@ func (m *Manager) processConnect(params *connection.ConnectParams, authMngr *aut
 	pkt := mqttp.NewConnAck(params.Version)
 
 	if params.Error != nil {
-		if e, ok := params.Error.(mqttp.ReasonCode); ok {
-			_ = pkt.SetReturnCode(e)
-			resp = pkt
+		// [MQTT-3.1.3-8] rejected client identifier is reported with CONNACK
+		if errors.Is(params.Error, mqttp.CodeRefusedIdentifierRejected) &&
+			pkt.SetReturnCode(mqttp.CodeRefusedIdentifierRejected) == nil {
+			return pkt, nil, nil
 		}
 		return resp, nil, params.Error
 	}
@@ -464,6 +469,8 @@ func (m *Manager) newSession(cn connection.Initial, params *connection.ConnectPa
 			version:               params.Version,
 			sharedSubscriptions:   m.Config.Options.SubsShared,
 			subscriptionIDAllowed: m.Config.Options.SubsID,
+			wildcardSubscriptions: m.Config.Options.SubsWildcard,
+			subscribePolicy:       m.Config.SubscribePolicy,
 			subscriber:            info.sub,
 		}
 
@@ -838,8 +845,10 @@ func (m *Manager) configurePersistedExpiry(ctx *loadContext) {
 
 func (m *Manager) processDelayedWills(ctx *loadContext) {
 	for _, will := range ctx.delayedWills {
-		if err := m.TopicsMgr.Publish(will); err != nil {
-			m.log.Error("Publish delayed will", zap.Error(err))
+		if pkt, ok := will.(*mqttp.Publish); ok && pkt != nil {
+			if err := publishWill(m.TopicsMgr, pkt); err != nil {
+				m.log.Error("Publish delayed will", zap.Error(err))
+			}
 		}
 	}
 }
@@ -1026,6 +1035,11 @@ func (m *Manager) persistSubscriber(s *subscriber.Type) {
 }
 
 func (m *Manager) sessionPersistPublish(id string, p *mqttp.Publish) {
+	// QoS0 messages are not persisted for offline sessions unless explicitly enabled
+	if p.QoS() == mqttp.QoS0 && !m.Options.OfflineQoS0 {
+		return
+	}
+
 	pkt := &vlpersistence.PersistedPacket{}
 
 	var expired bool
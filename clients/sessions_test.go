@@ -0,0 +1,105 @@
+package clients // nolint: testpackage
+
+import (
+	"net"
+	"testing"
+
+	"github.com/VolantMQ/vlapi/mqttp"
+	"github.com/VolantMQ/vlapi/vlpersistence"
+	"github.com/stretchr/testify/require"
+	persistenceMem "gitlab.com/VolantMQ/vlplugin/persistence/mem"
+
+	"github.com/VolantMQ/volantmq/metrics"
+	"github.com/VolantMQ/volantmq/types"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	persist, err := persistenceMem.Load(nil, nil)
+	require.NoError(t, err)
+
+	m := &Manager{
+		Config: Config{
+			Persist: persist,
+			Metrics: metrics.New(),
+		},
+	}
+
+	m.persistence, err = persist.Sessions()
+	require.NoError(t, err)
+
+	return m
+}
+
+func TestSessionPersistPublishQoS0(t *testing.T) {
+	for _, offlineQoS0 := range []bool{false, true} {
+		m := newTestManager(t)
+		m.Options.OfflineQoS0 = offlineQoS0
+
+		require.NoError(t, m.persistence.Create([]byte("test"), &vlpersistence.SessionBase{}))
+
+		for _, qos := range []mqttp.QosType{mqttp.QoS0, mqttp.QoS1, mqttp.QoS2} {
+			pkt := mqttp.NewPublish(mqttp.ProtocolV311)
+			require.NoError(t, pkt.Set("a/b", []byte("data"), qos, false, false))
+			m.sessionPersistPublish("test", pkt)
+		}
+
+		count, err := m.persistence.PacketCountQoS0([]byte("test"))
+		require.NoError(t, err)
+
+		if offlineQoS0 {
+			require.Equal(t, uint64(1), count)
+		} else {
+			require.Equal(t, uint64(0), count)
+		}
+
+		count, err = m.persistence.PacketCountQoS12([]byte("test"))
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), count)
+	}
+}
+
+func TestOnConnectionClientIDRejectedV31(t *testing.T) {
+	m := newTestManager(t)
+	m.Options.ReceiveMax = 10
+	m.Options.MaxPacketSize = types.DefaultMaxPacketSize
+	m.Options.ConnectTimeout = 2
+
+	server, client := net.Pipe()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	done := make(chan error)
+	go func() {
+		done <- m.OnConnection(server, nil)
+	}()
+
+	req := mqttp.NewConnect(mqttp.ProtocolV31)
+	req.SetClean(true)
+	require.NoError(t, req.SetClientID([]byte("abcdefghijklmnopqrstuvwx")))
+
+	buf, err := mqttp.Encode(req)
+	require.NoError(t, err)
+
+	_, err = client.Write(buf)
+	require.NoError(t, err)
+
+	buf = make([]byte, 128)
+	n, err := client.Read(buf)
+	require.NoError(t, err)
+
+	pkt, _, err := mqttp.Decode(mqttp.ProtocolV31, buf[:n])
+	require.NoError(t, err)
+	require.IsType(t, &mqttp.ConnAck{}, pkt)
+	require.Equal(t, mqttp.CodeRefusedIdentifierRejected, pkt.(*mqttp.ConnAck).ReturnCode())
+
+	require.NoError(t, <-done)
+
+	count := 0
+	m.sessions.Range(func(k, v interface{}) bool {
+		count++
+		return true
+	})
+
+	require.Equal(t, 0, count)
+}
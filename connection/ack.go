@@ -16,13 +16,20 @@ type ackQueue struct {
 func (a *ackQueue) store(pkt mqttp.IFace, replace bool) bool {
 	id, _ := pkt.ID()
 
-	if _, ok := a.messages.Load(id); ok && !replace {
-		return false
+	if replace {
+		a.messages.Store(id, pkt)
+		return true
 	}
 
-	a.messages.Store(id, pkt)
+	_, loaded := a.messages.LoadOrStore(id, pkt)
+
+	return !loaded
+}
+
+func (a *ackQueue) exists(id mqttp.IDType) bool {
+	_, ok := a.messages.Load(id)
 
-	return true
+	return ok
 }
 
 func (a *ackQueue) release(pkt mqttp.IFace) bool {
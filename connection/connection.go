@@ -16,7 +16,6 @@ package connection
 
 import (
 	"crypto/rand"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -41,6 +40,13 @@ var (
 	ErrConnectionNack = errors.New("connection: nack")
 )
 
+const (
+	// maxClientIDLenV31 v3.1 requires client identifier to be between 1 and 23 characters long
+	maxClientIDLenV31 = 23
+
+	clientIDChars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
 type state int
 
 const (
@@ -177,6 +183,7 @@ type impl struct {
 	permissions      vlauth.Permissions
 	authMethod       string
 	signalAuth       OnAuthCb
+	genClientID      ClientIDGenerator
 	onConnClose      func(error)
 	callStop         func(error) bool
 	tx               *writer
@@ -190,6 +197,7 @@ type impl struct {
 	connectProcessed uint32
 	rxQuota          int32
 	maxRxTopicAlias  uint16
+	maxRxQoS         mqttp.QosType
 	version          mqttp.ProtocolVersion
 	retainAvailable  bool
 }
@@ -234,10 +242,12 @@ func New(opts ...Option) (Initial, error) {
 	}()
 
 	s := &impl{
-		state: stateConnecting,
-		quit:  make(chan struct{}),
-		tx:    newWriter(),
-		rx:    newReader(),
+		state:       stateConnecting,
+		quit:        make(chan struct{}),
+		tx:          newWriter(),
+		rx:          newReader(),
+		maxRxQoS:    mqttp.QoS2,
+		genClientID: genClientID,
 	}
 
 	s.log = configuration.GetLogger().Named("connection")
@@ -351,6 +361,9 @@ func (s *impl) Acknowledge(p *mqttp.ConnAck, opts ...Option) error {
 			}
 		}
 	} else {
+		// [MQTT-3.2.2-6] session present must be 0 if server refuses connection
+		p.SetSessionPresent(false)
+
 		s.state = stateConnectFailed
 		ack = ErrConnectionNack
 	}
@@ -396,13 +409,28 @@ func (s *impl) Publish(_ string, pkt *mqttp.Publish) {
 	s.tx.send(pkt)
 }
 
+// genClientID default client identifier generator
+// produces identifier valid for any protocol version: 23 alphanumeric characters
 func genClientID() string {
-	b := make([]byte, 15)
-	if _, err := io.ReadFull(rand.Reader, b); err != nil {
-		return ""
+	// bytes above the largest multiple of alphabet size are dropped to keep distribution uniform
+	limit := byte(256 - 256%len(clientIDChars))
+
+	id := make([]byte, 0, maxClientIDLenV31)
+	b := make([]byte, maxClientIDLenV31)
+
+	for len(id) < maxClientIDLenV31 {
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			return ""
+		}
+
+		for _, c := range b {
+			if c < limit && len(id) < maxClientIDLenV31 {
+				id = append(id, clientIDChars[int(c)%len(clientIDChars)])
+			}
+		}
 	}
 
-	return base64.URLEncoding.EncodeToString(b)
+	return string(id)
 }
 
 func (s *impl) onConnect(pkt *mqttp.Connect) error {
@@ -411,7 +439,7 @@ func (s *impl) onConnect(pkt *mqttp.Connect) error {
 		idGen := false
 		if len(id) == 0 {
 			idGen = true
-			id = genClientID()
+			id = s.genClientID()
 		}
 
 		params := &ConnectParams{
@@ -425,6 +453,12 @@ func (s *impl) onConnect(pkt *mqttp.Connect) error {
 			Durable:    true,
 		}
 
+		// v3.1 does not allow server to assign client identifier
+		// and limits its length to 23 characters
+		if params.Version == mqttp.ProtocolV31 && (idGen || len(id) > maxClientIDLenV31) {
+			params.Error = mqttp.CodeRefusedIdentifierRejected
+		}
+
 		params.Username, params.Password = pkt.Credentials()
 		s.id = id
 		s.version = params.Version
@@ -577,6 +611,19 @@ func (s *impl) readConnProperties(req *mqttp.Connect, params *ConnectParams) {
 		}
 	}
 
+	// [MQTT-3.1.2-29] server must not send reason string or user properties on acks if value is 0
+	if prop := req.PropertyGet(mqttp.PropertyRequestProblemInfo); prop != nil {
+		if val, e := prop.AsByte(); e == nil {
+			e = s.tx.setOptions(
+				wrProblemInfo(val != 0),
+			)
+			if e != nil {
+				params.Error = e
+				return
+			}
+		}
+	}
+
 	// [MQTT-3.1.2.11.10]
 	if prop := req.PropertyGet(mqttp.PropertyAuthMethod); prop != nil {
 		if val, e := prop.AsString(); e == nil {
@@ -788,6 +835,12 @@ func (s *impl) onPublish(pkt *mqttp.Publish) (mqttp.IFace, error) {
 			return nil, mqttp.CodeRetainNotSupported
 		}
 
+		// [MQTT-3.2.2-11]
+		// client must not send PUBLISH with QoS greater than server advertised in Maximum QoS
+		if pkt.QoS() > s.maxRxQoS {
+			return nil, mqttp.CodeNotSupportedQoS
+		}
+
 		if prop := pkt.PropertyGet(mqttp.PropertyTopicAlias); prop != nil {
 			if val, ok := prop.AsShort(); ok == nil && (val == 0 || val > s.maxRxTopicAlias) {
 				return nil, mqttp.CodeInvalidTopicAlias
@@ -865,13 +918,14 @@ func (s *impl) onPublish(pkt *mqttp.Publish) (mqttp.IFace, error) {
 			return nil, mqttp.CodeProtocolError
 		}
 
-		if reason == mqttp.CodeSuccess {
-			// [MQTT-3.3.4-7]
-			if s.rxQuota == 0 {
-				return nil, mqttp.CodeReceiveMaximumExceeded
-			}
+		// [MQTT-4.3.3-10]
+		// until PUBREL received any PUBLISH with same packet id is a retransmit
+		// and must be acknowledged with PUBREC without being stored again
+		duplicate := s.pubIn.exists(id)
 
-			s.rxQuota--
+		// [MQTT-3.3.4-7]
+		if (reason == mqttp.CodeSuccess) && !duplicate && (s.rxQuota == 0) {
+			return nil, mqttp.CodeReceiveMaximumExceeded
 		}
 
 		r := mqttp.NewPubRec(s.version)
@@ -883,8 +937,8 @@ func (s *impl) onPublish(pkt *mqttp.Publish) (mqttp.IFace, error) {
 		// store incoming QoS 2 message before sending PUBREC as theoretically PUBREL
 		// might come before store in case message store done after write PUBREC
 		if reason == mqttp.CodeSuccess {
-			if !s.pubIn.store(pkt, false) {
-				reason = mqttp.CodePacketIDInUse
+			if s.pubIn.store(pkt, false) {
+				s.rxQuota--
 			}
 			// s.metric.OnAddUnAckRecv(1)
 		} else {
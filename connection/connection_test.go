@@ -0,0 +1,242 @@
+package connection // nolint: testpackage
+
+import (
+	"net"
+	"testing"
+
+	"github.com/VolantMQ/vlapi/mqttp"
+	"github.com/VolantMQ/vlapi/vlauth"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VolantMQ/volantmq/metrics"
+)
+
+type testPermissions struct{}
+
+var _ vlauth.Permissions = (*testPermissions)(nil)
+
+func (p *testPermissions) ACL(_, _, _ string, _ vlauth.AccessType) error {
+	return vlauth.StatusAllow
+}
+
+type testSession struct {
+	published []*mqttp.Publish
+}
+
+var _ SessionCallbacks = (*testSession)(nil)
+
+func (s *testSession) SignalPublish(pkt *mqttp.Publish) error {
+	s.published = append(s.published, pkt)
+	return nil
+}
+
+func (s *testSession) SignalSubscribe(*mqttp.Subscribe) (mqttp.IFace, error)     { return nil, nil }
+func (s *testSession) SignalUnSubscribe(*mqttp.UnSubscribe) (mqttp.IFace, error) { return nil, nil }
+func (s *testSession) SignalDisconnect(*mqttp.Disconnect) error                  { return nil }
+func (s *testSession) SignalOnline()                                             {}
+func (s *testSession) SignalOffline()                                            {}
+func (s *testSession) SignalConnectionClose(DisconnectParams)                    {}
+
+func newTestConnection(t *testing.T, v mqttp.ProtocolVersion) *impl {
+	cn, err := New(
+		Metric(metrics.New().Packets()),
+		RxQuota(10),
+		Permissions(&testPermissions{}),
+		AttachSession(&testSession{}),
+	)
+	require.NoError(t, err)
+
+	s, ok := cn.(*impl)
+	require.True(t, ok)
+
+	s.version = v
+
+	return s
+}
+
+func newTestPublish(t *testing.T, v mqttp.ProtocolVersion, topic string, qos mqttp.QosType, id mqttp.IDType) *mqttp.Publish {
+	pkt := mqttp.NewPublish(v)
+	require.NoError(t, pkt.Set(topic, []byte("data"), qos, false, false))
+
+	if qos != mqttp.QoS0 {
+		pkt.SetPacketID(id)
+	}
+
+	return pkt
+}
+
+func TestOnPublishQoS2Retransmit(t *testing.T) {
+	s := newTestConnection(t, mqttp.ProtocolV311)
+
+	resp, err := s.onPublish(newTestPublish(t, mqttp.ProtocolV311, "a/b", mqttp.QoS2, 1))
+	require.NoError(t, err)
+	require.IsType(t, &mqttp.Ack{}, resp)
+	require.Equal(t, mqttp.CodeSuccess, resp.(*mqttp.Ack).Reason())
+	require.Equal(t, int32(9), s.rxQuota)
+
+	dup := newTestPublish(t, mqttp.ProtocolV311, "a/b", mqttp.QoS2, 1)
+	dup.SetDup(true)
+
+	resp, err = s.onPublish(dup)
+	require.NoError(t, err)
+	require.IsType(t, &mqttp.Ack{}, resp)
+	require.Equal(t, mqttp.PUBREC, resp.Type())
+	require.Equal(t, mqttp.CodeSuccess, resp.(*mqttp.Ack).Reason())
+	require.Equal(t, int32(9), s.rxQuota)
+
+	count := 0
+	s.pubIn.messages.Range(func(k, v interface{}) bool {
+		count++
+		return true
+	})
+
+	require.Equal(t, 1, count)
+}
+
+func TestOnConnectClientIDV31(t *testing.T) {
+	tests := []struct {
+		name   string
+		id     string
+		reason error
+	}{
+		{name: "valid", id: "client", reason: nil},
+		{name: "max length", id: "abcdefghijklmnopqrstuvw", reason: nil},
+		{name: "too long", id: "abcdefghijklmnopqrstuvwx", reason: mqttp.CodeRefusedIdentifierRejected},
+		{name: "empty", id: "", reason: mqttp.CodeRefusedIdentifierRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestConnection(t, mqttp.ProtocolV31)
+			s.connect = make(chan interface{}, 1)
+
+			pkt := mqttp.NewConnect(mqttp.ProtocolV31)
+			pkt.SetClean(true)
+			if len(tt.id) > 0 {
+				require.NoError(t, pkt.SetClientID([]byte(tt.id)))
+			}
+
+			require.NoError(t, s.onConnect(pkt))
+
+			params, ok := (<-s.connect).(*ConnectParams)
+			require.True(t, ok)
+			require.Equal(t, tt.reason, params.Error)
+		})
+	}
+}
+
+func TestOnPublishMaxQoS(t *testing.T) {
+	s := newTestConnection(t, mqttp.ProtocolV50)
+	require.NoError(t, s.SetOptions(MaxRxQoS(mqttp.QoS1)))
+
+	_, err := s.onPublish(newTestPublish(t, mqttp.ProtocolV50, "a/b", mqttp.QoS2, 1))
+	require.Equal(t, mqttp.CodeNotSupportedQoS, err)
+
+	resp, err := s.onPublish(newTestPublish(t, mqttp.ProtocolV50, "a/b", mqttp.QoS1, 2))
+	require.NoError(t, err)
+	require.Equal(t, mqttp.PUBACK, resp.Type())
+
+	require.Error(t, s.SetOptions(MaxRxQoS(mqttp.QosType(3))))
+}
+
+func TestAcknowledgeRefusedSessionPresent(t *testing.T) {
+	server, client := net.Pipe()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	s := newTestConnection(t, mqttp.ProtocolV50)
+	require.NoError(t, s.SetOptions(NetConn(server)))
+	s.connect = make(chan interface{})
+
+	ack := mqttp.NewConnAck(mqttp.ProtocolV50)
+	require.NoError(t, ack.SetReturnCode(mqttp.CodeNotAuthorized))
+	ack.SetSessionPresent(true)
+
+	received := make(chan mqttp.IFace)
+	go func() {
+		buf := make([]byte, 128)
+		n, _ := client.Read(buf)
+		pkt, _, _ := mqttp.Decode(mqttp.ProtocolV50, buf[:n])
+		received <- pkt
+	}()
+
+	require.Equal(t, ErrConnectionNack, s.Acknowledge(ack))
+
+	pkt := <-received
+	require.IsType(t, &mqttp.ConnAck{}, pkt)
+	require.False(t, pkt.(*mqttp.ConnAck).SessionPresent())
+	require.Equal(t, mqttp.CodeNotAuthorized, pkt.(*mqttp.ConnAck).ReturnCode())
+}
+
+func TestOnPublishEmptyTopic(t *testing.T) {
+	s := newTestConnection(t, mqttp.ProtocolV50)
+	require.NoError(t, s.SetOptions(MaxRxTopicAlias(10)))
+
+	// register alias
+	pkt := newTestPublish(t, mqttp.ProtocolV50, "a/b", mqttp.QoS1, 1)
+	require.NoError(t, pkt.PropertySet(mqttp.PropertyTopicAlias, uint16(1)))
+
+	_, err := s.onPublish(pkt)
+	require.NoError(t, err)
+
+	// empty topic resolved through alias
+	pkt = newTestPublish(t, mqttp.ProtocolV50, "a/b", mqttp.QoS1, 2)
+	require.NoError(t, pkt.SetTopic(""))
+	require.NoError(t, pkt.PropertySet(mqttp.PropertyTopicAlias, uint16(1)))
+
+	_, err = s.onPublish(pkt)
+	require.NoError(t, err)
+	require.Equal(t, "a/b", pkt.Topic())
+
+	// empty topic with unknown alias
+	pkt = newTestPublish(t, mqttp.ProtocolV50, "a/b", mqttp.QoS1, 3)
+	require.NoError(t, pkt.SetTopic(""))
+	require.NoError(t, pkt.PropertySet(mqttp.PropertyTopicAlias, uint16(2)))
+
+	_, err = s.onPublish(pkt)
+	require.Equal(t, mqttp.CodeInvalidTopicAlias, err)
+}
+
+func TestGenClientID(t *testing.T) {
+	ids := make(map[string]bool)
+
+	for i := 0; i < 10000; i++ {
+		id := genClientID()
+		require.Len(t, id, maxClientIDLenV31)
+		require.False(t, ids[id])
+		ids[id] = true
+
+		pkt := mqttp.NewConnect(mqttp.ProtocolV31)
+		require.NoError(t, pkt.SetClientID([]byte(id)))
+	}
+}
+
+func TestOnConnectIDGenerator(t *testing.T) {
+	s := newTestConnection(t, mqttp.ProtocolV50)
+	require.NoError(t, s.SetOptions(IDGenerator(func() string { return "assigned" })))
+	s.connect = make(chan interface{}, 1)
+
+	pkt := mqttp.NewConnect(mqttp.ProtocolV50)
+	pkt.SetClean(true)
+
+	require.NoError(t, s.onConnect(pkt))
+
+	params, ok := (<-s.connect).(*ConnectParams)
+	require.True(t, ok)
+	require.True(t, params.IDGen)
+	require.Equal(t, "assigned", params.ID)
+}
+
+func TestOnConnectRequestProblemInfo(t *testing.T) {
+	s := newTestConnection(t, mqttp.ProtocolV50)
+	s.connect = make(chan interface{}, 1)
+
+	pkt := mqttp.NewConnect(mqttp.ProtocolV50)
+	pkt.SetClean(true)
+	require.NoError(t, pkt.SetClientID([]byte("client")))
+	require.NoError(t, pkt.PropertySet(mqttp.PropertyRequestProblemInfo, byte(0)))
+
+	require.NoError(t, s.onConnect(pkt))
+	require.False(t, s.tx.problemInfo)
+}
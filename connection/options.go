@@ -15,6 +15,10 @@ import (
 // OnAuthCb ...
 type OnAuthCb func(string, *AuthParams) (mqttp.IFace, error)
 
+// ClientIDGenerator produces client identifier assigned by server
+// when client connects with empty one
+type ClientIDGenerator func() string
+
 // Option callback for connection option
 type Option func(*impl) error
 
@@ -92,6 +96,16 @@ func MaxRxTopicAlias(val uint16) Option {
 	}
 }
 
+func MaxRxQoS(val mqttp.QosType) Option {
+	return func(t *impl) error {
+		if !val.IsValid() {
+			return mqttp.ErrInvalidQoS
+		}
+		t.maxRxQoS = val
+		return nil
+	}
+}
+
 func RetainAvailable(val bool) Option {
 	return func(t *impl) error {
 		t.retainAvailable = val
@@ -106,6 +120,16 @@ func OnAuth(val OnAuthCb) Option {
 	}
 }
 
+// IDGenerator replace default generator of assigned client identifiers
+func IDGenerator(val ClientIDGenerator) Option {
+	return func(t *impl) error {
+		if val != nil {
+			t.genClientID = val
+		}
+		return nil
+	}
+}
+
 func NetConn(val transport.Conn) Option {
 	return func(t *impl) error {
 		if t.conn != nil {
@@ -3,6 +3,7 @@ package connection
 import (
 	"bufio"
 	"encoding/binary"
+	"errors"
 	"sync"
 	"time"
 
@@ -170,5 +171,40 @@ func (s *reader) readPacket(buf *bufio.Reader) (mqttp.IFace, error) {
 	s.recv = []byte{}
 	s.remaining = 0
 
+	if err != nil {
+		err = reasonForError(err)
+	}
+
 	return pkt, err
 }
+
+// reasonForError translates packet decode error into reason code server reports to the client
+// errors not originated from decoder are returned as is
+// invalid protocol name or version is kept as is as CONNACK reason code depends on protocol version
+func reasonForError(err error) error {
+	var e mqttp.Error
+	if !errors.As(err, &e) {
+		return err
+	}
+
+	switch e {
+	case mqttp.ErrInvalidMessageType,
+		mqttp.ErrInvalidMessageTypeFlags,
+		mqttp.ErrInvalidQoS,
+		mqttp.ErrInvalidLength,
+		mqttp.ErrInsufficientBufferSize,
+		mqttp.ErrInsufficientDataSize,
+		mqttp.ErrInvalidLPStringSize,
+		mqttp.ErrMalformedTopic,
+		mqttp.ErrMalformedStream,
+		mqttp.ErrInvalidUtf8:
+		return mqttp.CodeMalformedPacket
+	case mqttp.ErrProtocolViolation,
+		mqttp.ErrDupViolation,
+		mqttp.ErrPackedIDZero,
+		mqttp.ErrInvalidTopic:
+		return mqttp.CodeProtocolError
+	}
+
+	return err
+}
@@ -0,0 +1,60 @@
+package connection // nolint: testpackage
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/VolantMQ/vlapi/mqttp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VolantMQ/volantmq/types"
+)
+
+func TestReasonForError(t *testing.T) {
+	tests := []struct {
+		err    error
+		reason error
+	}{
+		{err: mqttp.ErrInvalidMessageTypeFlags, reason: mqttp.CodeMalformedPacket},
+		{err: mqttp.ErrInsufficientBufferSize, reason: mqttp.CodeMalformedPacket},
+		{err: mqttp.ErrInvalidUtf8, reason: mqttp.CodeMalformedPacket},
+		{err: fmt.Errorf("decode: %w", mqttp.ErrInvalidUtf8), reason: mqttp.CodeMalformedPacket},
+		{err: mqttp.ErrProtocolViolation, reason: mqttp.CodeProtocolError},
+		{err: mqttp.ErrInvalidProtocolVersion, reason: mqttp.ErrInvalidProtocolVersion},
+		{err: mqttp.ErrProtocolInvalidName, reason: mqttp.ErrProtocolInvalidName},
+		{err: mqttp.CodeInvalidTopicAlias, reason: mqttp.CodeInvalidTopicAlias},
+		{err: io.EOF, reason: io.EOF},
+	}
+
+	for _, tt := range tests {
+		require.True(t, errors.Is(reasonForError(tt.err), tt.reason), tt.err.Error())
+	}
+}
+
+func TestReadPacketEmptyTopic(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		reason error
+	}{
+		// PUBLISH QoS0, zero length topic, no properties
+		{name: "no alias", data: []byte{0x30, 0x03, 0x00, 0x00, 0x00}, reason: mqttp.CodeProtocolError},
+		// PUBLISH QoS0, zero length topic, topic alias 1
+		{name: "alias", data: []byte{0x30, 0x06, 0x00, 0x00, 0x03, 0x23, 0x00, 0x01}, reason: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newReader()
+			s.version = mqttp.ProtocolV50
+			s.packetMaxSize = types.DefaultMaxPacketSize
+
+			_, err := s.readPacket(bufio.NewReader(bytes.NewReader(tt.data)))
+			require.Equal(t, tt.reason, err)
+		})
+	}
+}
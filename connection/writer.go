@@ -39,6 +39,7 @@ type writer struct {
 	pubrelMessages    *types.Queue
 	quit              chan struct{}
 	topicAlias        map[string]uint16
+	aliasTopic        map[uint16]string
 	log               *zap.SugaredLogger
 	onStop            types.Once
 	wg                sync.WaitGroup
@@ -50,6 +51,7 @@ type writer struct {
 	topicAliasCurrMax uint16
 	topicAliasMax     uint16
 	offlineQoS0       bool
+	problemInfo       bool
 	version           mqttp.ProtocolVersion
 }
 
@@ -62,6 +64,7 @@ type packetLoaderCtx struct {
 func newWriter() *writer {
 	w := &writer{
 		topicAlias:    make(map[string]uint16),
+		aliasTopic:    make(map[uint16]string),
 		quit:          make(chan struct{}),
 		topicAliasMax: 0,
 		flow: flow{
@@ -69,6 +72,7 @@ func newWriter() *writer {
 		},
 		running:       0,
 		packetMaxSize: maxPacketSize,
+		problemInfo:   true,
 	}
 	w.gMessages = types.NewQueue()
 	w.qos0Messages = types.NewQueue()
@@ -200,6 +204,7 @@ func (s *writer) stop() {
 
 func (s *writer) shutdown() {
 	s.topicAlias = nil
+	s.aliasTopic = nil
 }
 
 func (s *writer) send(pkt mqttp.IFace) {
@@ -377,6 +382,7 @@ func (s *writer) routine() {
 				}
 
 				p.SetVersion(s.version)
+				s.discardProblemInfo(p)
 
 				if buf, e := mqttp.Encode(p); e != nil {
 					s.log.Error("packet encode", zap.String("ClientID", s.id), zap.Error(err))
@@ -441,10 +447,13 @@ func (s *writer) setTopicAlias(pkt *mqttp.Publish) {
 				s.topicAliasCurrMax++
 				alias = s.topicAliasCurrMax
 			} else {
-				alias = uint16(rand.Intn(int(s.topicAliasMax))) // nolint: gosec
+				// table is full, evict random alias. [MQTT-3.3.2-8] alias 0 is not permitted
+				alias = uint16(rand.Intn(int(s.topicAliasMax))) + 1 // nolint: gosec
+				delete(s.topicAlias, s.aliasTopic[alias])
 			}
 
 			s.topicAlias[pkt.Topic()] = alias
+			s.aliasTopic[alias] = pkt.Topic()
 		}
 
 		if err := pkt.PropertySet(mqttp.PropertyTopicAlias, alias); err == nil && exists {
@@ -453,6 +462,21 @@ func (s *writer) setTopicAlias(pkt *mqttp.Publish) {
 	}
 }
 
+// discardProblemInfo removes reason string and user properties from acknowledgements
+// if client has not requested problem information
+// [MQTT-3.1.2-29]
+func (s *writer) discardProblemInfo(p mqttp.IFace) {
+	if s.problemInfo {
+		return
+	}
+
+	switch p.Type() {
+	case mqttp.PUBACK, mqttp.PUBREC, mqttp.PUBREL, mqttp.PUBCOMP, mqttp.SUBACK, mqttp.UNSUBACK:
+		// reason string and user properties are the only properties these packets carry
+		p.PropertiesDiscard()
+	}
+}
+
 func (s *writer) packetFitsSize(value interface{}) bool {
 	var sz int
 	var err error
@@ -553,7 +577,9 @@ func (s *writer) getQueuedPackets() vlpersistence.PersistedPackets {
 				pkt = tp
 			}
 		case *unacknowledged:
-			if pb, ok := p.(*mqttp.Publish); ok && pb.QoS() == mqttp.QoS1 {
+			// [MQTT-3.3.1-1] unacknowledged PUBLISH will be redelivered thus must be marked as duplicate
+			// [MQTT-3.3.1-2] QoS 0 never gets here
+			if pb, ok := tp.IFace.(*mqttp.Publish); ok && pb.QoS() != mqttp.QoS0 {
 				pb.SetDup(true)
 			}
 
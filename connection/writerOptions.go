@@ -95,3 +95,10 @@ func wrLog(val *zap.SugaredLogger) writerOption {
 		return nil
 	}
 }
+
+func wrProblemInfo(val bool) writerOption {
+	return func(t *writer) error {
+		t.problemInfo = val
+		return nil
+	}
+}
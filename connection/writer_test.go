@@ -0,0 +1,90 @@
+package connection // nolint: testpackage
+
+import (
+	"testing"
+
+	"github.com/VolantMQ/vlapi/mqttp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VolantMQ/volantmq/metrics"
+)
+
+func TestWriterQueuedPacketsDup(t *testing.T) {
+	w := newWriter()
+	require.NoError(t, w.setOptions(wrMetric(metrics.New().Packets())))
+
+	for i, qos := range []mqttp.QosType{mqttp.QoS1, mqttp.QoS2} {
+		w.pubOut.store(newTestPublish(t, mqttp.ProtocolV311, "a/b", qos, mqttp.IDType(i+1)), true)
+	}
+
+	packets := w.getQueuedPackets()
+	require.Len(t, packets.UnAck, 2)
+
+	for _, p := range packets.UnAck {
+		require.NotNil(t, p)
+
+		pkt, _, err := mqttp.Decode(mqttp.ProtocolV311, p.Data)
+		require.NoError(t, err)
+		require.IsType(t, &mqttp.Publish{}, pkt)
+		require.True(t, pkt.(*mqttp.Publish).Dup())
+	}
+}
+
+func TestWriterTopicAlias(t *testing.T) {
+	w := newWriter()
+	require.NoError(t, w.setOptions(wrTopicAliasMax(1)))
+
+	publish := func(topic string) *mqttp.Publish {
+		pkt := newTestPublish(t, mqttp.ProtocolV50, topic, mqttp.QoS0, 0)
+		w.setTopicAlias(pkt)
+
+		prop := pkt.PropertyGet(mqttp.PropertyTopicAlias)
+		require.NotNil(t, prop)
+
+		alias, err := prop.AsShort()
+		require.NoError(t, err)
+		require.Equal(t, uint16(1), alias)
+
+		return pkt
+	}
+
+	require.Equal(t, "a/b", publish("a/b").Topic())
+	require.Equal(t, "", publish("a/b").Topic())
+	require.Equal(t, "", publish("a/b").Topic())
+
+	// table full, alias reassigned to new topic
+	require.Equal(t, "c/d", publish("c/d").Topic())
+	require.Equal(t, "a/b", publish("a/b").Topic())
+	require.Equal(t, "", publish("a/b").Topic())
+}
+
+func TestWriterDiscardProblemInfo(t *testing.T) {
+	newAck := func() *mqttp.Ack {
+		pkt := mqttp.NewPubAck(mqttp.ProtocolV50)
+		pkt.SetPacketID(1)
+		require.NoError(t, pkt.PropertySet(mqttp.PropertyReasonString, "reason"))
+		return pkt
+	}
+
+	newPublish := func() *mqttp.Publish {
+		pkt := newTestPublish(t, mqttp.ProtocolV50, "a/b", mqttp.QoS1, 1)
+		require.NoError(t, pkt.PropertySet(mqttp.PropertyUserProperty, []mqttp.StringPair{{K: "k", V: "v"}}))
+		return pkt
+	}
+
+	w := newWriter()
+
+	ack := newAck()
+	w.discardProblemInfo(ack)
+	require.NotNil(t, ack.PropertyGet(mqttp.PropertyReasonString))
+
+	require.NoError(t, w.setOptions(wrProblemInfo(false)))
+
+	ack = newAck()
+	w.discardProblemInfo(ack)
+	require.Nil(t, ack.PropertyGet(mqttp.PropertyReasonString))
+
+	pub := newPublish()
+	w.discardProblemInfo(pub)
+	require.NotNil(t, pub.PropertyGet(mqttp.PropertyUserProperty))
+}
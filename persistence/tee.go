@@ -0,0 +1,266 @@
+package persistence
+
+import (
+	"fmt"
+
+	"github.com/VolantMQ/vlapi/vlpersistence"
+	"go.uber.org/zap"
+
+	"github.com/VolantMQ/volantmq/configuration"
+)
+
+type mirror struct {
+	log    *zap.SugaredLogger
+	strict bool
+	// secondary backend could not be opened, writes go to primary only
+	primaryOnly bool
+}
+
+type tee struct {
+	mirror
+	primary   vlpersistence.IFace
+	secondary vlpersistence.IFace
+}
+
+type teeSessions struct {
+	mirror
+	pSessions vlpersistence.Sessions
+	sSessions vlpersistence.Sessions
+}
+
+type teeRetained struct {
+	mirror
+	pRetained vlpersistence.Retained
+	sRetained vlpersistence.Retained
+}
+
+var _ vlpersistence.IFace = (*tee)(nil)
+var _ vlpersistence.Sessions = (*teeSessions)(nil)
+var _ vlpersistence.Retained = (*teeRetained)(nil)
+
+// Tee persistence provider mirroring writes into both primary and secondary backends
+// all reads are served by primary.
+// If strict is false errors of secondary are logged only, otherwise returned to caller
+// System is not mirrored and served by primary only.
+// Server does not wrap backends itself, callers set the result as server.Config.Persistence
+func Tee(primary, secondary vlpersistence.IFace, strict bool) vlpersistence.IFace {
+	return &tee{
+		mirror: mirror{
+			strict: strict,
+			log:    configuration.GetLogger().Named("persistence").Named("tee"),
+		},
+		primary:   primary,
+		secondary: secondary,
+	}
+}
+
+// write apply op to primary and if succeeded to secondary
+func (m *mirror) write(op string, primary, secondary func() error) error {
+	if err := primary(); err != nil {
+		return err
+	}
+
+	if m.primaryOnly {
+		return nil
+	}
+
+	return m.secondaryError(op, secondary())
+}
+
+// secondaryError returns err in strict mode, otherwise logs it
+func (m *mirror) secondaryError(op string, err error) error {
+	if err == nil || m.strict {
+		return err
+	}
+
+	m.log.Warn("secondary backend", zap.String("op", op), zap.Error(err))
+
+	return nil
+}
+
+func (t *tee) Sessions() (vlpersistence.Sessions, error) {
+	primary, err := t.primary.Sessions()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &teeSessions{
+		mirror:    t.mirror,
+		pSessions: primary,
+	}
+
+	if s.sSessions, err = t.secondary.Sessions(); err != nil {
+		if err = t.secondaryError("sessions", err); err != nil {
+			return nil, err
+		}
+
+		s.primaryOnly = true
+	}
+
+	return s, nil
+}
+
+func (t *tee) Retained() (vlpersistence.Retained, error) {
+	primary, err := t.primary.Retained()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &teeRetained{
+		mirror:    t.mirror,
+		pRetained: primary,
+	}
+
+	if r.sRetained, err = t.secondary.Retained(); err != nil {
+		if err = t.secondaryError("retained", err); err != nil {
+			return nil, err
+		}
+
+		r.primaryOnly = true
+	}
+
+	return r, nil
+}
+
+func (t *tee) System() (vlpersistence.System, error) {
+	return t.primary.System()
+}
+
+// Shutdown both backends regardless of primary result
+func (t *tee) Shutdown() error {
+	err := t.primary.Shutdown()
+
+	if e := t.secondaryError("shutdown", t.secondary.Shutdown()); e != nil {
+		if err == nil {
+			return e
+		}
+
+		return fmt.Errorf("%w; secondary: %v", err, e)
+	}
+
+	return err
+}
+
+func (s *teeSessions) PacketCountQoS0(id []byte) (uint64, error) {
+	return s.pSessions.PacketCountQoS0(id)
+}
+
+func (s *teeSessions) PacketCountQoS12(id []byte) (uint64, error) {
+	return s.pSessions.PacketCountQoS12(id)
+}
+
+func (s *teeSessions) PacketCountUnAck(id []byte) (uint64, error) {
+	return s.pSessions.PacketCountUnAck(id)
+}
+
+func (s *teeSessions) PacketStoreQoS0(id []byte, pkt *vlpersistence.PersistedPacket) error {
+	return s.write("packet store qos0",
+		func() error { return s.pSessions.PacketStoreQoS0(id, pkt) },
+		func() error { return s.sSessions.PacketStoreQoS0(id, pkt) })
+}
+
+func (s *teeSessions) PacketStoreQoS12(id []byte, pkt *vlpersistence.PersistedPacket) error {
+	return s.write("packet store qos12",
+		func() error { return s.pSessions.PacketStoreQoS12(id, pkt) },
+		func() error { return s.sSessions.PacketStoreQoS12(id, pkt) })
+}
+
+func (s *teeSessions) PacketsForEachQoS0(id []byte, ctx interface{}, loader vlpersistence.PacketLoader) error {
+	return s.pSessions.PacketsForEachQoS0(id, ctx, loader)
+}
+
+func (s *teeSessions) PacketsForEachQoS12(id []byte, ctx interface{}, loader vlpersistence.PacketLoader) error {
+	return s.pSessions.PacketsForEachQoS12(id, ctx, loader)
+}
+
+func (s *teeSessions) PacketsForEachUnAck(id []byte, ctx interface{}, loader vlpersistence.PacketLoader) error {
+	return s.pSessions.PacketsForEachUnAck(id, ctx, loader)
+}
+
+func (s *teeSessions) PacketsStore(id []byte, packets vlpersistence.PersistedPackets) error {
+	return s.write("packets store",
+		func() error { return s.pSessions.PacketsStore(id, packets) },
+		func() error { return s.sSessions.PacketsStore(id, packets) })
+}
+
+func (s *teeSessions) PacketsDelete(id []byte) error {
+	return s.write("packets delete",
+		func() error { return s.pSessions.PacketsDelete(id) },
+		func() error { return s.sSessions.PacketsDelete(id) })
+}
+
+func (s *teeSessions) SubscriptionsStore(id []byte, data []byte) error {
+	return s.write("subscriptions store",
+		func() error { return s.pSessions.SubscriptionsStore(id, data) },
+		func() error { return s.sSessions.SubscriptionsStore(id, data) })
+}
+
+func (s *teeSessions) SubscriptionsDelete(id []byte) error {
+	return s.write("subscriptions delete",
+		func() error { return s.pSessions.SubscriptionsDelete(id) },
+		func() error { return s.sSessions.SubscriptionsDelete(id) })
+}
+
+func (s *teeSessions) StateStore(id []byte, state *vlpersistence.SessionState) error {
+	return s.write("state store",
+		func() error { return s.pSessions.StateStore(id, state) },
+		func() error { return s.sSessions.StateStore(id, state) })
+}
+
+func (s *teeSessions) StateDelete(id []byte) error {
+	return s.write("state delete",
+		func() error { return s.pSessions.StateDelete(id) },
+		func() error { return s.sSessions.StateDelete(id) })
+}
+
+func (s *teeSessions) ExpiryStore(id []byte, delays *vlpersistence.SessionDelays) error {
+	return s.write("expiry store",
+		func() error { return s.pSessions.ExpiryStore(id, delays) },
+		func() error { return s.sSessions.ExpiryStore(id, delays) })
+}
+
+func (s *teeSessions) ExpiryDelete(id []byte) error {
+	return s.write("expiry delete",
+		func() error { return s.pSessions.ExpiryDelete(id) },
+		func() error { return s.sSessions.ExpiryDelete(id) })
+}
+
+func (s *teeSessions) Create(id []byte, state *vlpersistence.SessionBase) error {
+	return s.write("create",
+		func() error { return s.pSessions.Create(id, state) },
+		func() error { return s.sSessions.Create(id, state) })
+}
+
+func (s *teeSessions) Count() uint64 {
+	return s.pSessions.Count()
+}
+
+func (s *teeSessions) LoadForEach(loader vlpersistence.SessionLoader, ctx interface{}) error {
+	return s.pSessions.LoadForEach(loader, ctx)
+}
+
+func (s *teeSessions) Exists(id []byte) bool {
+	return s.pSessions.Exists(id)
+}
+
+func (s *teeSessions) Delete(id []byte) error {
+	return s.write("delete",
+		func() error { return s.pSessions.Delete(id) },
+		func() error { return s.sSessions.Delete(id) })
+}
+
+func (r *teeRetained) Store(packets []*vlpersistence.PersistedPacket) error {
+	return r.write("retained store",
+		func() error { return r.pRetained.Store(packets) },
+		func() error { return r.sRetained.Store(packets) })
+}
+
+func (r *teeRetained) Load() ([]*vlpersistence.PersistedPacket, error) {
+	return r.pRetained.Load()
+}
+
+func (r *teeRetained) Wipe() error {
+	return r.write("retained wipe",
+		func() error { return r.pRetained.Wipe() },
+		func() error { return r.sRetained.Wipe() })
+}
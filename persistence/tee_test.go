@@ -0,0 +1,82 @@
+package persistence // nolint: testpackage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/VolantMQ/vlapi/vlpersistence"
+	"github.com/stretchr/testify/require"
+	persistenceMem "gitlab.com/VolantMQ/vlplugin/persistence/mem"
+)
+
+func newTestBackend(t *testing.T) vlpersistence.IFace {
+	p, err := persistenceMem.Load(nil, nil)
+	require.NoError(t, err)
+
+	return p.(vlpersistence.IFace)
+}
+
+func TestTeeMirrorsWrites(t *testing.T) {
+	primary := newTestBackend(t)
+	secondary := newTestBackend(t)
+
+	sessions, err := Tee(primary, secondary, true).Sessions()
+	require.NoError(t, err)
+
+	require.NoError(t, sessions.Create([]byte("test"), &vlpersistence.SessionBase{}))
+	require.True(t, sessions.Exists([]byte("test")))
+
+	for _, p := range []vlpersistence.IFace{primary, secondary} {
+		s, err := p.Sessions()
+		require.NoError(t, err)
+		require.True(t, s.Exists([]byte("test")))
+	}
+
+	require.NoError(t, sessions.Delete([]byte("test")))
+
+	for _, p := range []vlpersistence.IFace{primary, secondary} {
+		s, err := p.Sessions()
+		require.NoError(t, err)
+		require.False(t, s.Exists([]byte("test")))
+	}
+}
+
+func TestTeeSecondaryError(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		primary := newTestBackend(t)
+		secondary := newTestBackend(t)
+		require.NoError(t, secondary.Shutdown())
+
+		p := Tee(primary, secondary, strict)
+
+		sessions, err := p.Sessions()
+		retained, e := p.Retained()
+
+		if strict {
+			require.Equal(t, vlpersistence.ErrNotOpen, err)
+			require.Equal(t, vlpersistence.ErrNotOpen, e)
+			require.Error(t, p.Shutdown())
+			continue
+		}
+
+		// secondary not available, writes go to primary only
+		require.NoError(t, err)
+		require.NoError(t, e)
+		require.NoError(t, sessions.Create([]byte("test"), &vlpersistence.SessionBase{}))
+		require.True(t, sessions.Exists([]byte("test")))
+		require.NoError(t, retained.Wipe())
+		require.NoError(t, p.Shutdown())
+	}
+}
+
+func TestTeeShutdownPrimaryError(t *testing.T) {
+	primary := newTestBackend(t)
+	secondary := newTestBackend(t)
+	require.NoError(t, primary.Shutdown())
+
+	err := Tee(primary, secondary, false).Shutdown()
+	require.True(t, errors.Is(err, vlpersistence.ErrNotOpen))
+
+	// secondary must be closed as well
+	require.Equal(t, vlpersistence.ErrNotOpen, secondary.Shutdown())
+}
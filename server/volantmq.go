@@ -15,6 +15,7 @@ import (
 
 	"github.com/VolantMQ/volantmq/clients"
 	"github.com/VolantMQ/volantmq/configuration"
+	"github.com/VolantMQ/volantmq/connection"
 	"github.com/VolantMQ/volantmq/metrics"
 	"github.com/VolantMQ/volantmq/topics"
 	topicsTypes "github.com/VolantMQ/volantmq/topics/types"
@@ -52,6 +53,14 @@ type Config struct {
 	// If not not set than defaults to mock function
 	OnDuplicate func(string, bool)
 
+	// SubscribePolicy optional callback to refuse subscriptions to filters considered too wide
+	// If not set all filters are allowed
+	SubscribePolicy clients.SubscribePolicy
+
+	// GenClientID optional generator of identifiers assigned to clients connecting with empty id
+	// If not set defaults to random 23 characters alphanumeric string
+	GenClientID connection.ClientIDGenerator
+
 	// TransportStatus user provided callback to track transport status
 	// If not set than defaults to mock function
 	TransportStatus func(id string, status string)
@@ -129,6 +138,7 @@ func NewServer(config Config) (Server, error) {
 	topicsConfig.MetricsSubs = s.Metrics.Subs()
 	topicsConfig.Persist = persisRetained
 	topicsConfig.OverlappingSubscriptions = s.MQTT.Options.SubsOverlap
+	topicsConfig.MaxQos = s.MQTT.Options.MaxQoS
 
 	if s.topicsMgr, err = topics.New(topicsConfig); err != nil {
 		s.log.Errorf("cannot create topics")
@@ -143,6 +153,8 @@ func NewServer(config Config) (Server, error) {
 		Persist:          s.Persistence,
 		Metrics:          s.Metrics,
 		OnReplaceAttempt: s.OnDuplicate,
+		SubscribePolicy:  s.SubscribePolicy,
+		GenClientID:      s.GenClientID,
 		NodeName:         s.NodeName,
 	}
 
@@ -47,6 +47,7 @@ type provider struct {
 	inRetained         chan vltypes.RetainObject
 	subIn              chan topicstypes.SubscribeReq
 	unSubIn            chan topicstypes.UnSubscribeReq
+	maxQos             mqttp.QosType
 	allowOverlapping   bool
 }
 
@@ -62,6 +63,7 @@ func NewMemProvider(config *topicstypes.MemConfig) (topicstypes.Provider, error)
 		metricsSubs:        config.MetricsSubs,
 		persist:            config.Persist,
 		onCleanUnsubscribe: config.OnCleanUnsubscribe,
+		maxQos:             config.MaxQos,
 		inbound:            make(chan *mqttp.Publish, chanSize),
 		inRetained:         make(chan vltypes.RetainObject, chanSize),
 		subIn:              make(chan topicstypes.SubscribeReq, chanSize),
@@ -77,6 +79,8 @@ func NewMemProvider(config *topicstypes.MemConfig) (topicstypes.Provider, error)
 			return nil, err
 		}
 
+		var valid []*vlpersistence.PersistedPacket
+
 		for _, d := range entries {
 			v := mqttp.ProtocolVersion(d.Data[0])
 			var pkt mqttp.IFace
@@ -93,12 +97,28 @@ func NewMemProvider(config *topicstypes.MemConfig) (topicstypes.Provider, error)
 							p.log.Error("Decode publish expire at", zap.Error(err))
 						}
 					}
+
+					// message might have expired while server was down
+					if _, _, expired := m.Expired(); expired {
+						continue
+					}
+
+					valid = append(valid, d)
+
 					_ = p.Retain(m)
 				} else {
 					p.log.Warn("Unsupported retained message type", zap.String("type", m.Type().Name()))
 				}
 			}
 		}
+
+		// drop persisted records of expired messages
+		// as snapshot written on shutdown contains only messages being retained
+		if len(valid) != len(entries) {
+			if err = p.persist.Store(valid); err != nil {
+				p.log.Error("Couldn't persist retained messages", zap.Error(err))
+			}
+		}
 	}
 
 	publisherCount := 1
@@ -288,6 +308,10 @@ func (mT *provider) subscriber() {
 				resp.Err = mqttp.ErrInvalidQoS
 			} else {
 				req.Params.Granted = req.Params.Ops.QoS()
+				// server does not grant QoS greater than it supports
+				if req.Params.Granted > mT.maxQos {
+					req.Params.Granted = mT.maxQos
+				}
 				resp.Params = req.Params
 
 				exists := mT.subscriptionInsert(req.Filter, req.S, req.Params)
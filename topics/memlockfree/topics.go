@@ -47,6 +47,7 @@ type provider struct {
 	unSubIn            chan topicstypes.UnSubscribeReq
 	onCleanUnsubscribe func([]string)
 	nodeSubscribers    func(sn *node, publishID uintptr, p *publishes)
+	maxQos             mqttp.QosType
 }
 
 var _ topicstypes.Provider = (*provider)(nil)
@@ -61,6 +62,7 @@ func NewMemProvider(config *topicstypes.MemConfig) (topicstypes.Provider, error)
 		metricsSubs:        config.MetricsSubs,
 		persist:            config.Persist,
 		onCleanUnsubscribe: config.OnCleanUnsubscribe,
+		maxQos:             config.MaxQos,
 		inbound:            make(chan *mqttp.Publish, chanSize),
 		inRetained:         make(chan vltypes.RetainObject, chanSize),
 		subIn:              make(chan topicstypes.SubscribeReq, chanSize),
@@ -83,6 +85,8 @@ func NewMemProvider(config *topicstypes.MemConfig) (topicstypes.Provider, error)
 			return nil, err
 		}
 
+		var valid []*vlpersistence.PersistedPacket
+
 		for _, d := range entries {
 			var pkt mqttp.IFace
 			pkt, _, err = mqttp.Decode(mqttp.ProtocolV50, d.Data)
@@ -98,12 +102,28 @@ func NewMemProvider(config *topicstypes.MemConfig) (topicstypes.Provider, error)
 							p.log.Error("Decode publish expire at", zap.Error(err))
 						}
 					}
+
+					// message might have expired while server was down
+					if _, _, expired := m.Expired(); expired {
+						continue
+					}
+
+					valid = append(valid, d)
+
 					_ = p.Retain(m)
 				} else {
 					p.log.Warn("Unsupported retained message type", zap.String("type", m.Type().Name()))
 				}
 			}
 		}
+
+		// drop persisted records of expired messages
+		// as snapshot written on shutdown contains only messages being retained
+		if len(valid) != len(entries) {
+			if err = p.persist.Store(valid); err != nil {
+				p.log.Error("Couldn't persist retained messages", zap.Error(err))
+			}
+		}
 	}
 
 	publisherCount := 2
@@ -273,6 +293,10 @@ func (mT *provider) subscribe(req topicstypes.SubscribeReq) topicstypes.Subscrib
 			resp.Err = mqttp.ErrInvalidQoS
 		} else {
 			req.Params.Granted = req.Params.Ops.QoS()
+			// server does not grant QoS greater than it supports
+			if req.Params.Granted > mT.maxQos {
+				req.Params.Granted = mT.maxQos
+			}
 			resp.Params = req.Params
 
 			exists := mT.subscriptionInsert(req.Filter, req.S, req.Params)
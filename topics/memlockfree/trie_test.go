@@ -2,11 +2,14 @@ package memlockfree // nolint: testpackage
 
 import (
 	"testing"
+	"time"
 
 	"github.com/VolantMQ/vlapi/mqttp"
+	"github.com/VolantMQ/vlapi/vlpersistence"
 	"github.com/VolantMQ/vlapi/vlsubscriber"
 	"github.com/VolantMQ/vlapi/vltypes"
 	"github.com/stretchr/testify/require"
+	persistenceMem "gitlab.com/VolantMQ/vlplugin/persistence/mem"
 
 	"github.com/VolantMQ/volantmq/metrics"
 	"github.com/VolantMQ/volantmq/subscriber"
@@ -29,7 +32,11 @@ func init() {
 }
 
 func allocProvider(t *testing.T) *provider {
-	prov, err := NewMemProvider(config)
+	return allocProviderWithConfig(t, config)
+}
+
+func allocProviderWithConfig(t *testing.T, c *topicstypes.MemConfig) *provider {
+	prov, err := NewMemProvider(c)
 	require.NoError(t, err)
 
 	if p, ok := prov.(*provider); ok {
@@ -733,3 +740,78 @@ func newPublishMessageLarge(topic string, qos mqttp.QosType) *mqttp.Publish {
 
 	return msg
 }
+
+func TestRetainLoadExpired(t *testing.T) {
+	persist, err := persistenceMem.Load(nil, nil)
+	require.NoError(t, err)
+
+	retained, err := persist.Retained()
+	require.NoError(t, err)
+
+	encode := func(topic string, expireAt time.Time) *vlpersistence.PersistedPacket {
+		pkt := mqttp.NewPublish(mqttp.ProtocolV50)
+		require.NoError(t, pkt.Set(topic, []byte("data"), mqttp.QoS1, true, false))
+		pkt.SetPacketID(1)
+
+		buf, e := mqttp.Encode(pkt)
+		require.NoError(t, e)
+
+		return &vlpersistence.PersistedPacket{
+			Data:     buf,
+			ExpireAt: expireAt.Format(time.RFC3339),
+		}
+	}
+
+	require.NoError(t, retained.Store([]*vlpersistence.PersistedPacket{
+		encode("sport/expired", time.Now().Add(-time.Second)),
+		encode("sport/valid", time.Now().Add(time.Hour)),
+	}))
+
+	cfg := *config
+	cfg.Persist = retained
+
+	prov := allocProviderWithConfig(t, &cfg)
+
+	// persisted record of expired message is removed at load
+	entries, err := retained.Load()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// shutdown waits for retainer to apply loaded messages
+	require.NoError(t, prov.Shutdown())
+
+	// snapshot written on shutdown holds only the valid message
+	entries, err = retained.Load()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	pkt, _, err := mqttp.Decode(mqttp.ProtocolV50, entries[0].Data)
+	require.NoError(t, err)
+	require.IsType(t, &mqttp.Publish{}, pkt)
+	require.Equal(t, "sport/valid", pkt.(*mqttp.Publish).Topic())
+}
+
+func TestSubscribeMaxQoS(t *testing.T) {
+	cfg := *config
+	cfg.MaxQos = mqttp.QoS1
+
+	prov := allocProviderWithConfig(t, &cfg)
+	sub := &subscriber.Type{}
+
+	req := topicstypes.SubscribeReq{
+		Filter: testTopic1,
+		S:      sub,
+		Params: vlsubscriber.SubscriptionParams{
+			Ops: mqttp.SubscriptionOptions(mqttp.QoS2),
+		},
+	}
+
+	resp := prov.Subscribe(req)
+	require.NoError(t, resp.Err)
+	require.Equal(t, mqttp.QoS1, resp.Params.Granted)
+
+	req.Params.Ops = mqttp.SubscriptionOptions(mqttp.QoS0)
+	resp = prov.Subscribe(req)
+	require.NoError(t, resp.Err)
+	require.Equal(t, mqttp.QoS0, resp.Params.Granted)
+}